@@ -0,0 +1,99 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	mountBlockSize     = 1 * 1024 * 1024       // Block granularity for cached reads, matching manifestBlockSize
+	mountPerFileBudget = 100 * 1024 * 1024      // Maximum cached bytes per file
+	mountGlobalBudget  = 1 * 1024 * 1024 * 1024 // Maximum cached bytes across all mounted files
+)
+
+// cacheKey identifies one cached block of one mounted file.
+type cacheKey struct {
+	file  string
+	block int64
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	data []byte
+}
+
+// blockCache is an LRU cache of fixed-size file blocks fetched on demand for
+// a -mount session, bounded by both a per-file and a global byte budget so
+// browsing many large remote files can't exhaust memory.
+type blockCache struct {
+	mu          sync.Mutex
+	order       *list.List
+	entries     map[cacheKey]*list.Element
+	perFileSize map[string]int64
+	totalSize   int64
+}
+
+// newBlockCache creates an empty cache governed by mountPerFileBudget and
+// mountGlobalBudget.
+func newBlockCache() *blockCache {
+	return &blockCache{
+		order:       list.New(),
+		entries:     make(map[cacheKey]*list.Element),
+		perFileSize: make(map[string]int64),
+	}
+}
+
+// get returns a cached block, if present, and marks it most-recently-used.
+func (c *blockCache) get(file string, block int64) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[cacheKey{file, block}]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).data, true
+}
+
+// put stores a fetched block, evicting the least-recently-used blocks until
+// both the per-file and global budgets are satisfied again.
+func (c *blockCache) put(file string, block int64, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey{file, block}
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		old := elem.Value.(*cacheEntry)
+		delta := int64(len(data)) - int64(len(old.data))
+		c.totalSize += delta
+		c.perFileSize[file] += delta
+		old.data = data
+		c.evict(file)
+		return
+	}
+
+	elem := c.order.PushFront(&cacheEntry{key: key, data: data})
+	c.entries[key] = elem
+	c.perFileSize[file] += int64(len(data))
+	c.totalSize += int64(len(data))
+
+	c.evict(file)
+}
+
+// evict drops the globally least-recently-used blocks until file is within
+// mountPerFileBudget and the cache as a whole is within mountGlobalBudget.
+func (c *blockCache) evict(file string) {
+	for c.perFileSize[file] > mountPerFileBudget || c.totalSize > mountGlobalBudget {
+		back := c.order.Back()
+		if back == nil {
+			return
+		}
+		entry := back.Value.(*cacheEntry)
+		c.order.Remove(back)
+		delete(c.entries, entry.key)
+		c.perFileSize[entry.key.file] -= int64(len(entry.data))
+		c.totalSize -= int64(len(entry.data))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"net"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressedConn wraps a net.Conn and, when enabled, transparently zstd
+// compresses writes and decompresses reads over one continuous stream for
+// the lifetime of the connection.
+type CompressedConn struct {
+	net.Conn
+	enabled bool
+	writer  *zstd.Encoder
+	reader  *zstd.Decoder
+}
+
+// newCompressedConn wraps conn for zstd compression if enabled is true,
+// using level for outgoing data.
+func newCompressedConn(conn net.Conn, enabled bool, level zstd.EncoderLevel) (*CompressedConn, error) {
+	cc := &CompressedConn{Conn: conn, enabled: enabled}
+	if !enabled {
+		return cc, nil
+	}
+
+	writer, err := zstd.NewWriter(conn, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zstd.NewReader(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	cc.writer = writer
+	cc.reader = reader
+	return cc, nil
+}
+
+// Write compresses p and flushes it immediately so the peer sees it without
+// waiting for the encoder's internal buffering to fill up.
+func (cc *CompressedConn) Write(p []byte) (int, error) {
+	if !cc.enabled {
+		return cc.Conn.Write(p)
+	}
+	n, err := cc.writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, cc.writer.Flush()
+}
+
+// Read returns decompressed bytes read from the underlying connection.
+func (cc *CompressedConn) Read(p []byte) (int, error) {
+	if !cc.enabled {
+		return cc.Conn.Read(p)
+	}
+	return cc.reader.Read(p)
+}
+
+// Close tears down the zstd stream, if any, and closes the underlying connection.
+func (cc *CompressedConn) Close() error {
+	if cc.enabled {
+		cc.writer.Close()
+		cc.reader.Close()
+	}
+	return cc.Conn.Close()
+}
+
+// sendCompressionChoice tells the peer whether this session will be zstd
+// compressed. Must be called once, right after the connection (and any PAKE
+// handshake) is established, before any file data is exchanged.
+func sendCompressionChoice(conn net.Conn, enabled bool) error {
+	flag := byte(0)
+	if enabled {
+		flag = 1
+	}
+	_, err := conn.Write([]byte{flag})
+	return err
+}
+
+// receiveCompressionChoice reads the choice written by sendCompressionChoice.
+func receiveCompressionChoice(conn net.Conn) (bool, error) {
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return false, err
+	}
+	return buf[0] == 1, nil
+}
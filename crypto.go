@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/schollz/pake/v3"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	pakeCurve       = "siec"           // Curve used for the PAKE key exchange
+	pakeTimeout     = 30 * time.Second // Time allowed for the handshake to complete
+	secureChunkSize = 32 * 1024        // Plaintext payload size per sealed frame
+	nonceSize       = 12               // chacha20poly1305 standard nonce length
+	tagSize         = 16               // chacha20poly1305 authentication tag length
+	maxHandshakeMsg = 4 * 1024         // Generous bound on a PAKE handshake message
+)
+
+// SecureConn wraps a net.Conn and transparently seals/opens every read and
+// write as length-prefixed chacha20poly1305 frames, using a symmetric key
+// derived from a PAKE handshake performed over the same connection.
+type SecureConn struct {
+	net.Conn
+	sealer     cipher.AEAD
+	opener     cipher.AEAD
+	writeNonce uint64 // Monotonic counter, this side's write direction
+	readNonce  uint64 // Monotonic counter, peer's write direction
+	readBuf    []byte // Leftover decrypted plaintext not yet consumed
+}
+
+// deriveKeys expands the PAKE session key into independent sender->receiver
+// and receiver->sender keys so the same session key is never reused for
+// both directions of traffic.
+func deriveKeys(sessionKey []byte) (toReceiver, toSender [32]byte) {
+	toReceiver = blake2b.Sum256(append([]byte("onion-transfer-s2r:"), sessionKey...))
+	toSender = blake2b.Sum256(append([]byte("onion-transfer-r2s:"), sessionKey...))
+	return
+}
+
+// secureHandshake performs a PAKE key exchange over conn using code as the
+// shared weak secret, then returns conn wrapped in a SecureConn. isSender
+// selects which PAKE role (and which derived key) this side plays.
+func secureHandshake(conn net.Conn, code string, isSender bool) (*SecureConn, error) {
+	role := 1
+	if isSender {
+		role = 0
+	}
+
+	p, err := pake.InitCurve([]byte(code), role, pakeCurve)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize PAKE: %w", err)
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(pakeTimeout)); err != nil {
+		return nil, fmt.Errorf("failed to set handshake deadline: %w", err)
+	}
+
+	if isSender {
+		if err := writeFrame(conn, p.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to send PAKE message: %w", err)
+		}
+		peerMsg, err := readFrame(conn)
+		if err != nil {
+			return nil, fmt.Errorf("PAKE handshake did not complete: %w", err)
+		}
+		if err := p.Update(peerMsg); err != nil {
+			return nil, fmt.Errorf("PAKE key exchange failed: %w", err)
+		}
+	} else {
+		peerMsg, err := readFrame(conn)
+		if err != nil {
+			return nil, fmt.Errorf("PAKE handshake did not complete: %w", err)
+		}
+		if err := p.Update(peerMsg); err != nil {
+			return nil, fmt.Errorf("PAKE key exchange failed: %w", err)
+		}
+		if err := writeFrame(conn, p.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to send PAKE message: %w", err)
+		}
+	}
+
+	sessionKey, err := p.SessionKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive session key: %w", err)
+	}
+
+	// Clear the handshake deadline; transfer timeouts are handled elsewhere.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to clear handshake deadline: %w", err)
+	}
+
+	toReceiver, toSender := deriveKeys(sessionKey)
+	writeKey, readKey := toReceiver, toSender
+	if !isSender {
+		writeKey, readKey = toSender, toReceiver
+	}
+
+	sealer, err := chacha20poly1305.New(writeKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sealer: %w", err)
+	}
+	opener, err := chacha20poly1305.New(readKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create opener: %w", err)
+	}
+
+	return &SecureConn{Conn: conn, sealer: sealer, opener: opener}, nil
+}
+
+// writeFrame writes a length-prefixed, unencrypted message used only during
+// the PAKE handshake itself.
+func writeFrame(conn net.Conn, payload []byte) error {
+	if err := binary.Write(conn, binary.BigEndian, uint32(len(payload))); err != nil {
+		return err
+	}
+	_, err := conn.Write(payload)
+	return err
+}
+
+// readFrame reads a length-prefixed, unencrypted message used only during
+// the PAKE handshake itself.
+func readFrame(conn net.Conn) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > maxHandshakeMsg {
+		return nil, fmt.Errorf("handshake message too large: %d bytes", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// nextNonce renders a counter as a chacha20poly1305 nonce. Frames are
+// strictly ordered on a TCP stream, so both sides derive the same nonce
+// for the same frame index without exchanging it.
+func nextNonce(counter uint64) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[nonceSize-8:], counter)
+	return nonce
+}
+
+// Write seals p as one or more chacha20poly1305 frames and writes them to
+// the underlying connection, each prefixed with its encrypted length.
+func (sc *SecureConn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > secureChunkSize {
+			chunk = chunk[:secureChunkSize]
+		}
+
+		sealed := sc.sealer.Seal(nil, nextNonce(sc.writeNonce), chunk, nil)
+		sc.writeNonce++
+
+		if err := binary.Write(sc.Conn, binary.BigEndian, uint32(len(sealed))); err != nil {
+			return written, err
+		}
+		if _, err := sc.Conn.Write(sealed); err != nil {
+			return written, err
+		}
+
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Read returns decrypted plaintext, pulling and opening one more frame from
+// the underlying connection whenever the internal buffer runs dry.
+func (sc *SecureConn) Read(p []byte) (int, error) {
+	for len(sc.readBuf) == 0 {
+		var frameLen uint32
+		if err := binary.Read(sc.Conn, binary.BigEndian, &frameLen); err != nil {
+			return 0, err
+		}
+		if frameLen > secureChunkSize+tagSize {
+			return 0, fmt.Errorf("sealed frame too large: %d bytes", frameLen)
+		}
+
+		sealed := make([]byte, frameLen)
+		if _, err := io.ReadFull(sc.Conn, sealed); err != nil {
+			return 0, err
+		}
+
+		plain, err := sc.opener.Open(nil, nextNonce(sc.readNonce), sealed, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt frame: %w", err)
+		}
+		sc.readNonce++
+		sc.readBuf = plain
+	}
+
+	n := copy(p, sc.readBuf)
+	sc.readBuf = sc.readBuf[n:]
+	return n, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sendDirectoryRaw announces a directory with a single FileInfo (FileSize -1
+// marks an archive of unknown length) and then streams its contents as one
+// tar archive, instead of one FileInfo/body round trip per entry.
+func sendDirectoryRaw(conn net.Conn, dirPath string) error {
+	absDirPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return err
+	}
+
+	rootDirInfo := FileInfo{
+		Filename:    filepath.Base(dirPath),
+		FileSize:    -1,
+		IsDirectory: true,
+	}
+
+	if err := sendFileInfo(conn, rootDirInfo); err != nil {
+		return fmt.Errorf("failed to send root directory info: %w", err)
+	}
+
+	fmt.Printf("Sending directory: %s\n", rootDirInfo.Filename)
+
+	baseName := filepath.Base(absDirPath)
+	tw := tar.NewWriter(conn)
+
+	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if path == dirPath {
+			return nil
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(absDirPath, absPath)
+		if err != nil {
+			return err
+		}
+		fullRelPath := filepath.ToSlash(filepath.Join(baseName, relPath))
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = fullRelPath
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			n, err := io.Copy(tw, file)
+			if streamAgg != nil {
+				streamAgg.add(n)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stream directory %s: %w", dirPath, err)
+	}
+
+	return tw.Close()
+}
+
+// receiveDirectoryTar reads the single tar archive announced by
+// sendDirectoryRaw and extracts it under destDir, rejecting any entry that
+// would escape destDir via ".." or an absolute path.
+func receiveDirectoryTar(conn net.Conn, destDir string, allowSymlinks bool) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	tr := tar.NewReader(conn)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("malformed directory archive: %w", err)
+		}
+
+		name := filepath.ToSlash(header.Name)
+		if filepath.IsAbs(name) {
+			return fmt.Errorf("refusing archive entry with absolute path: %s", header.Name)
+		}
+		for _, part := range strings.Split(name, "/") {
+			if part == ".." {
+				return fmt.Errorf("refusing archive entry with path traversal: %s", header.Name)
+			}
+		}
+
+		targetPath := filepath.Join(destDir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(targetPath, 0755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return err
+			}
+			file.Close()
+
+		case tar.TypeSymlink:
+			if !allowSymlinks {
+				fmt.Printf("  Skipping symlink %s (pass -allow-symlinks to keep it)\n", name)
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return err
+			}
+			os.Remove(targetPath)
+			if err := os.Symlink(header.Linkname, targetPath); err != nil {
+				return err
+			}
+
+		default:
+			// Ignore device nodes, fifos, and other unsupported entry types.
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/klauspost/compress/zstd"
+)
+
+// runMount accepts a single connection from a sender running in -serve
+// mode, reads its catalog of streamable files, and exposes them as a
+// read-only FUSE filesystem at mountDir. File contents are fetched on
+// demand through an LRU block cache rather than written to receiveDir.
+func runMount(mountDir string, code string, externalTor bool) error {
+	if err := os.MkdirAll(mountDir, 0755); err != nil {
+		return fmt.Errorf("cannot create mount point: %w", err)
+	}
+
+	var listener net.Listener
+
+	if externalTor {
+		l, err := net.Listen("tcp", port)
+		if err != nil {
+			return fmt.Errorf("cannot start server: %w", err)
+		}
+		listener = l
+		fmt.Println("Listening for connections via externally configured Tor hidden service...")
+	} else {
+		t, onion, address, err := startEmbeddedTorListener(torServicePort)
+		if err != nil {
+			return fmt.Errorf("cannot start embedded Tor: %w", err)
+		}
+		defer t.Close()
+		listener = onion
+		fmt.Printf("Onion address: %s\n", address)
+		fmt.Println("Waiting for the onion service to become reachable, then listening...")
+	}
+	defer listener.Close()
+
+	rawConn, err := listener.Accept()
+	if err != nil {
+		return fmt.Errorf("connection error: %w", err)
+	}
+	defer rawConn.Close()
+
+	secureConn, err := secureHandshake(rawConn, code, false)
+	if err != nil {
+		return fmt.Errorf("PAKE handshake failed: %w", err)
+	}
+
+	compress, err := receiveCompressionChoice(secureConn)
+	if err != nil {
+		return fmt.Errorf("failed to negotiate compression: %w", err)
+	}
+	conn, err := newCompressedConn(secureConn, compress, zstd.SpeedDefault)
+	if err != nil {
+		return fmt.Errorf("failed to set up compression: %w", err)
+	}
+
+	catalog := make(map[string]FileInfo)
+	for {
+		fi, err := receiveFileInfo(conn)
+		if err != nil {
+			return fmt.Errorf("failed to receive catalog: %w", err)
+		}
+		if fi.EndOfCatalog {
+			break
+		}
+		catalog[fi.Filename] = *fi
+	}
+
+	fmt.Printf("Mounting %d remote file(s) read-only at %s...\n", len(catalog), mountDir)
+
+	session := &mountSession{conn: conn, catalog: catalog, cache: newBlockCache()}
+	root := buildMountTree(catalog, session)
+
+	c, err := fuse.Mount(mountDir, fuse.ReadOnly(), fuse.FSName("oniontransfer"), fuse.Subtype("oniontransferfs"))
+	if err != nil {
+		return fmt.Errorf("failed to mount FUSE filesystem: %w", err)
+	}
+	defer c.Close()
+
+	if err := fs.Serve(c, &mountRoot{root: root}); err != nil {
+		return fmt.Errorf("FUSE server error: %w", err)
+	}
+
+	return nil
+}
+
+// buildMountTree arranges a flat catalog of "a/b/c.txt"-style relative
+// paths into a tree of mountDirNode directories with mountFile leaves.
+func buildMountTree(catalog map[string]FileInfo, session *mountSession) *mountDirNode {
+	root := &mountDirNode{children: make(map[string]fs.Node)}
+
+	for name, fi := range catalog {
+		parts := strings.Split(filepath.ToSlash(name), "/")
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := dir.children[part]
+			if !ok {
+				child = &mountDirNode{children: make(map[string]fs.Node)}
+				dir.children[part] = child
+			}
+			dir = child.(*mountDirNode)
+		}
+		dir.children[parts[len(parts)-1]] = &mountFile{info: fi, session: session}
+	}
+
+	return root
+}
+
+// mountRoot is the fs.FS implementation handed to fuse/fs.Serve.
+type mountRoot struct {
+	root *mountDirNode
+}
+
+func (r *mountRoot) Root() (fs.Node, error) {
+	return r.root, nil
+}
+
+// mountDirNode is a directory in the mounted, read-only catalog tree.
+type mountDirNode struct {
+	children map[string]fs.Node
+}
+
+func (d *mountDirNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = os.ModeDir | 0555
+	return nil
+}
+
+func (d *mountDirNode) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	if n, ok := d.children[name]; ok {
+		return n, nil
+	}
+	return nil, fuse.ENOENT
+}
+
+func (d *mountDirNode) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	entries := make([]fuse.Dirent, 0, len(d.children))
+	for name, child := range d.children {
+		typ := fuse.DT_File
+		if _, ok := child.(*mountDirNode); ok {
+			typ = fuse.DT_Dir
+		}
+		entries = append(entries, fuse.Dirent{Name: name, Type: typ})
+	}
+	return entries, nil
+}
+
+// mountFile is a read-only leaf whose contents are fetched on demand via
+// mountSession.fetch rather than stored on disk.
+type mountFile struct {
+	info    FileInfo
+	session *mountSession
+}
+
+func (f *mountFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Mode = 0444
+	a.Size = uint64(f.info.FileSize)
+	return nil
+}
+
+func (f *mountFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	data, err := f.session.fetch(f.info.Filename, req.Offset, req.Size)
+	if err != nil {
+		return err
+	}
+	resp.Data = data
+	return nil
+}
+
+// mountSession serializes range requests to the sender over one persistent
+// connection and caches fetched blocks in an LRU block cache, so repeated
+// or overlapping reads of the same region don't re-hit the network.
+type mountSession struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	catalog map[string]FileInfo
+	cache   *blockCache
+}
+
+// fetch returns up to length bytes of filename starting at offset,
+// assembling them from cached blocks and fetching whichever 1 MiB blocks
+// are missing.
+func (s *mountSession) fetch(filename string, offset int64, length int) ([]byte, error) {
+	fi, ok := s.catalog[filename]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+
+	end := offset + int64(length)
+	if end > fi.FileSize {
+		end = fi.FileSize
+	}
+	if end <= offset {
+		return nil, nil
+	}
+
+	result := make([]byte, 0, end-offset)
+	for pos := offset; pos < end; {
+		blockIndex := pos / mountBlockSize
+		blockStart := blockIndex * mountBlockSize
+
+		block, ok := s.cache.get(filename, blockIndex)
+		if !ok {
+			blockLen := int64(mountBlockSize)
+			if blockStart+blockLen > fi.FileSize {
+				blockLen = fi.FileSize - blockStart
+			}
+			data, err := s.fetchRange(filename, blockStart, int(blockLen))
+			if err != nil {
+				return nil, err
+			}
+			s.cache.put(filename, blockIndex, data)
+			block = data
+		}
+
+		offsetInBlock := pos - blockStart
+		available := int64(len(block)) - offsetInBlock
+		remaining := end - pos
+		n := available
+		if remaining < n {
+			n = remaining
+		}
+		if n <= 0 {
+			break
+		}
+		result = append(result, block[offsetInBlock:offsetInBlock+n]...)
+		pos += n
+	}
+
+	return result, nil
+}
+
+// fetchRange issues one rangeRequest over the shared connection and waits
+// for its reply. Only one request can be in flight at a time per mount.
+func (s *mountSession) fetchRange(filename string, offset int64, length int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := sendRangeRequest(s.conn, rangeRequest{Filename: filename, Offset: offset, Length: int32(length)}); err != nil {
+		return nil, err
+	}
+	return receiveRangeData(s.conn)
+}
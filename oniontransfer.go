@@ -11,15 +11,18 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"golang.org/x/net/proxy"
+	"github.com/cretz/bine/tor"
+	"github.com/klauspost/compress/zstd"
 )
 
 const (
 	receiveDir     = "received"       // Directory where received files are stored
 	port           = ":8000"          // Port for network communication
-	torProxyAddr   = "127.0.0.1:9050" // Tor proxy address
+	torServicePort = 8000             // Same port as above, as an int for the onion service config
+	torProxyAddr   = "127.0.0.1:9050" // Tor proxy address, used only with -external-tor
 	chunkSize      = 32 * 1024        // 32KB chunks for better performance
 	maxFilenameLen = 255              // Maximum filename length
 )
@@ -31,9 +34,12 @@ var (
 
 // FileInfo contains metadata about the file being transferred
 type FileInfo struct {
-	Filename    string // Name of the file
-	FileSize    int64  // Size of the file in bytes
-	IsDirectory bool   // Whether this is a directory
+	Filename     string // Name of the file
+	FileSize     int64  // Size of the file in bytes
+	IsDirectory  bool   // Whether this is a directory
+	HasManifest  bool   // Whether a resume manifest follows this FileInfo
+	Streamable   bool   // Whether this entry is served on demand for a -mount session instead of pushed
+	EndOfCatalog bool   // Marks the end of a -serve catalog; carries no file of its own
 }
 
 // ProgressWriter tracks and displays transfer progress
@@ -186,7 +192,34 @@ func sendFileInfo(conn net.Conn, fi FileInfo) error {
 	if _, err := conn.Write([]byte{isDir}); err != nil {
 		return err
 	}
-	
+
+	// Send manifest flag (1 byte)
+	hasManifest := byte(0)
+	if fi.HasManifest {
+		hasManifest = 1
+	}
+	if _, err := conn.Write([]byte{hasManifest}); err != nil {
+		return err
+	}
+
+	// Send streamable flag (1 byte)
+	streamable := byte(0)
+	if fi.Streamable {
+		streamable = 1
+	}
+	if _, err := conn.Write([]byte{streamable}); err != nil {
+		return err
+	}
+
+	// Send end-of-catalog flag (1 byte)
+	endOfCatalog := byte(0)
+	if fi.EndOfCatalog {
+		endOfCatalog = 1
+	}
+	if _, err := conn.Write([]byte{endOfCatalog}); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -222,150 +255,134 @@ func receiveFileInfo(conn net.Conn) (*FileInfo, error) {
 		return nil, err
 	}
 	isDirectory := dirFlag[0] == 1
-	
+
+	// Read manifest flag
+	manifestFlag := make([]byte, 1)
+	if _, err := io.ReadFull(conn, manifestFlag); err != nil {
+		return nil, err
+	}
+	hasManifest := manifestFlag[0] == 1
+
+	// Read streamable flag
+	streamableFlag := make([]byte, 1)
+	if _, err := io.ReadFull(conn, streamableFlag); err != nil {
+		return nil, err
+	}
+	streamable := streamableFlag[0] == 1
+
+	// Read end-of-catalog flag
+	endOfCatalogFlag := make([]byte, 1)
+	if _, err := io.ReadFull(conn, endOfCatalogFlag); err != nil {
+		return nil, err
+	}
+	endOfCatalog := endOfCatalogFlag[0] == 1
+
 	return &FileInfo{
-		Filename:    filename,
-		FileSize:    fileSize,
-		IsDirectory: isDirectory,
+		Filename:     filename,
+		FileSize:     fileSize,
+		IsDirectory:  isDirectory,
+		HasManifest:  hasManifest,
+		Streamable:   streamable,
+		EndOfCatalog: endOfCatalog,
 	}, nil
 }
 
-// sendSingleFile sends a single file with custom filename
+// sendSingleFile sends a single file with custom filename, negotiating a
+// resume offset from a content-hash manifest before streaming the body.
 func sendSingleFile(conn net.Conn, filePath string, filename string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	
+
 	fileInfo, err := file.Stat()
 	if err != nil {
 		return err
 	}
-	
+
 	// Send file metadata with custom filename (could be relative path)
 	fi := FileInfo{
 		Filename:    filename,
 		FileSize:    fileInfo.Size(),
 		IsDirectory: false,
+		HasManifest: true,
 	}
-	
+
 	if err := sendFileInfo(conn, fi); err != nil {
 		return fmt.Errorf("failed to send file info: %w", err)
 	}
-	
-	// Create progress tracker
-	progressWriter = NewProgressWriter(fi.FileSize, filepath.Base(filename))
-	defer progressWriter.Finalize()
-	
-	fmt.Printf("  Sending %s (%s)...\n", filename, formatBytes(float64(fi.FileSize)))
-	
+
+	fullHash, blockHashes, err := computeManifest(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to build resume manifest: %w", err)
+	}
+	if err := sendManifest(conn, fullHash, blockHashes); err != nil {
+		return fmt.Errorf("failed to send resume manifest: %w", err)
+	}
+
+	resumeOffset, err := receiveResumeOffset(conn)
+	if err != nil {
+		return fmt.Errorf("failed to receive resume offset: %w", err)
+	}
+	if resumeOffset > 0 {
+		if _, err := file.Seek(resumeOffset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek to resume offset: %w", err)
+		}
+	}
+
+	// When several streams are sending concurrently, a single shared
+	// streamProgress replaces the per-file console line (which isn't safe
+	// to share across goroutines).
+	if streamAgg == nil {
+		progressWriter = NewProgressWriter(fi.FileSize, filepath.Base(filename))
+		progressWriter.current = resumeOffset
+		defer progressWriter.Finalize()
+	}
+
+	if resumeOffset > 0 {
+		fmt.Printf("  Resuming %s at %s of %s...\n", filename, formatBytes(float64(resumeOffset)), formatBytes(float64(fi.FileSize)))
+	} else {
+		fmt.Printf("  Sending %s (%s)...\n", filename, formatBytes(float64(fi.FileSize)))
+	}
+
 	// Send file data
 	buffer := make([]byte, chunkSize)
-	totalSent := int64(0)
-	
+	totalSent := resumeOffset
+
 	for {
 		n, err := file.Read(buffer)
 		if err != nil && err != io.EOF {
 			return err
 		}
-		
+
 		if n == 0 {
 			break
 		}
-		
+
 		if _, err := conn.Write(buffer[:n]); err != nil {
 			return err
 		}
-		
+
 		totalSent += int64(n)
-		progressWriter.current = totalSent
-		progressWriter.printProgress()
-		
+		if streamAgg != nil {
+			streamAgg.add(int64(n))
+		} else {
+			progressWriter.current = totalSent
+			progressWriter.printProgress()
+		}
+
 		if err == io.EOF {
 			break
 		}
 	}
-	
-	progressWriter.current = fi.FileSize
-	progressWriter.printProgress()
-	
-	return nil
-}
 
-// sendDirectoryRaw sends a directory recursively
-func sendDirectoryRaw(conn net.Conn, dirPath string) error {
-	// Get the absolute path to calculate relative paths correctly
-	absDirPath, err := filepath.Abs(dirPath)
-	if err != nil {
-		return err
-	}
-	
-	// Send the root directory marker
-	rootDirInfo := FileInfo{
-		Filename:    filepath.Base(dirPath),
-		FileSize:    0,
-		IsDirectory: true,
-	}
-	
-	if err := sendFileInfo(conn, rootDirInfo); err != nil {
-		return fmt.Errorf("failed to send root directory info: %w", err)
+	if streamAgg == nil {
+		progressWriter.current = fi.FileSize
+		progressWriter.printProgress()
 	}
-	
-	fmt.Printf("Sending directory: %s\n", rootDirInfo.Filename)
-	
-	// Walk through the directory
-	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		
-		// Skip the root directory itself (we already sent it)
-		if path == dirPath {
-			return nil
-		}
-		
-		// Get absolute path for this item
-		absPath, err := filepath.Abs(path)
-		if err != nil {
-			return err
-		}
-		
-		// Calculate relative path from the directory being sent
-		relPath, err := filepath.Rel(absDirPath, absPath)
-		if err != nil {
-			return err
-		}
-		
-		// Prepend the directory name to the relative path
-		// Wenn wir oc/ senden, sollte config.json als oc/config.json gesendet werden
-		baseName := filepath.Base(absDirPath)
-		fullRelPath := filepath.Join(baseName, relPath)
-		
-		// Convert to forward slashes for consistency
-		fullRelPath = filepath.ToSlash(fullRelPath)
-		
-		if info.IsDir() {
-			// Send subdirectory
-			subDirInfo := FileInfo{
-				Filename:    fullRelPath,
-				FileSize:    0,
-				IsDirectory: true,
-			}
-			
-			if err := sendFileInfo(conn, subDirInfo); err != nil {
-				return fmt.Errorf("failed to send subdirectory info: %w", err)
-			}
-			fmt.Printf("  Sending subdirectory: %s\n", fullRelPath)
-		} else {
-			// Send file with full relative path
-			if err := sendSingleFile(conn, path, fullRelPath); err != nil {
-				return fmt.Errorf("failed to send file %s: %w", path, err)
-			}
-		}
-		
-		return nil
-	})
+
+	return nil
 }
 
 // sendFileRaw sends a file or directory using raw TCP connection
@@ -385,40 +402,50 @@ func sendFileRaw(conn net.Conn, filePath string) error {
 }
 
 // receiveFileRaw receives a file using raw TCP connection
-func receiveFileRaw(conn net.Conn) error {
+func receiveFileRaw(secureConn net.Conn, allowSymlinks bool) error {
+	compress, err := receiveCompressionChoice(secureConn)
+	if err != nil {
+		return fmt.Errorf("failed to negotiate compression: %w", err)
+	}
+
+	conn, err := newCompressedConn(secureConn, compress, zstd.SpeedDefault)
+	if err != nil {
+		return fmt.Errorf("failed to set up compression: %w", err)
+	}
+
 	// Keep receiving files until connection is closed
 	for {
 		// Receive file metadata
 		fi, err := receiveFileInfo(conn)
 		if err != nil {
 			// Check if it's EOF (connection closed)
-			if err == io.EOF || strings.Contains(err.Error(), "closed") || 
-			   strings.Contains(err.Error(), "reset") || 
-			   strings.Contains(err.Error(), "unexpected EOF") {
+			if err == io.EOF || strings.Contains(err.Error(), "closed") ||
+				strings.Contains(err.Error(), "reset") ||
+				strings.Contains(err.Error(), "unexpected EOF") {
 				return nil // Normal connection termination
 			}
 			return fmt.Errorf("failed to receive file info: %w", err)
 		}
-		
+
 		// Ensure receive directory exists
 		if err := os.MkdirAll(receiveDir, 0755); err != nil {
 			return fmt.Errorf("cannot create directory: %w", err)
 		}
-		
+
 		// Create full path - use the received filename which may include subdirectories
 		// Normalize path separators
 		normalizedPath := filepath.FromSlash(fi.Filename)
 		fullPath := filepath.Join(receiveDir, normalizedPath)
-		
+
 		if fi.IsDirectory {
-			// Create directory (including parent directories if needed)
-			if err := os.MkdirAll(fullPath, 0755); err != nil {
-				return fmt.Errorf("cannot create directory: %w", err)
+			// A directory is announced once, then streamed as a single tar archive.
+			if err := receiveDirectoryTar(conn, fullPath, allowSymlinks); err != nil {
+				return fmt.Errorf("failed to receive directory %s: %w", fi.Filename, err)
 			}
-			fmt.Printf("Directory created: %s\n", fullPath)
+			fmt.Printf("Directory received: %s\n", fullPath)
 			continue
 		}
-		
+
 		// For files, ensure parent directory exists
 		parentDir := filepath.Dir(fullPath)
 		if parentDir != "." && parentDir != receiveDir {
@@ -426,124 +453,125 @@ func receiveFileRaw(conn net.Conn) error {
 				return fmt.Errorf("cannot create parent directory: %w", err)
 			}
 		}
-		
-		fmt.Printf("Receiving %s (%s)...\n", fi.Filename, formatBytes(float64(fi.FileSize)))
-		
-		// Create file with original filename
-		file, err := os.Create(fullPath)
-		if err != nil {
-			return fmt.Errorf("cannot create file: %w", err)
-		}
-		
-		// Create progress tracker
-		progressWriter = NewProgressWriter(fi.FileSize, filepath.Base(fi.Filename))
-		
-		// Receive file data in chunks
-		buffer := make([]byte, chunkSize)
-		totalReceived := int64(0)
-	
-		for totalReceived < fi.FileSize {
-			// Calculate remaining bytes
-			remaining := fi.FileSize - totalReceived
-			readSize := chunkSize
-			if remaining < int64(chunkSize) {
-				readSize = int(remaining)
-			}
-			
-			// Read chunk from connection
-			n, err := io.ReadFull(conn, buffer[:readSize])
-			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-				file.Close()
-				return err
-			}
-			
-			// Write chunk to file
-			if _, err := file.Write(buffer[:n]); err != nil {
-				file.Close()
+
+		if !fi.HasManifest {
+			// No resume manifest was offered (e.g. stdin transfers); write straight through.
+			if err := receiveFileBody(conn, fullPath, fi, 0); err != nil {
 				return err
 			}
-			
-			totalReceived += int64(n)
-			progressWriter.current = totalReceived
-			progressWriter.printProgress()
-			
-			if totalReceived >= fi.FileSize {
-				break
-			}
+			fmt.Printf("\nFile received: %s\n\n", fullPath)
+			continue
 		}
-		
-		// Close file and finalize progress
-		file.Close()
-		
-		// Force final progress update to show 100%
-		progressWriter.current = fi.FileSize
-		progressWriter.printProgress()
-		progressWriter.Finalize()
-		
+
+		fullHash, blockHashes, err := receiveManifest(conn)
+		if err != nil {
+			return fmt.Errorf("failed to receive resume manifest: %w", err)
+		}
+
+		partPath := fullPath + ".part"
+		resumeOffset, err := findResumeOffset(partPath, blockHashes, fi.FileSize)
+		if err != nil {
+			return fmt.Errorf("failed to inspect partial file: %w", err)
+		}
+
+		if err := sendResumeOffset(conn, resumeOffset); err != nil {
+			return fmt.Errorf("failed to send resume offset: %w", err)
+		}
+
+		if err := receiveFileBody(conn, partPath, fi, resumeOffset); err != nil {
+			return err
+		}
+
+		match, err := verifyFullHash(partPath, fullHash)
+		if err != nil {
+			return fmt.Errorf("failed to verify received file: %w", err)
+		}
+		if !match {
+			return fmt.Errorf("hash mismatch receiving %s, keeping %s for a future resume", fi.Filename, partPath)
+		}
+
+		if err := os.Rename(partPath, fullPath); err != nil {
+			return fmt.Errorf("cannot finalize received file: %w", err)
+		}
+
 		fmt.Printf("\nFile received: %s\n\n", fullPath)
 	}
 }
 
 // startServerRaw starts a raw TCP server for receiving files
-func startServerRaw() {
+func startServerRaw(code string, allowSymlinks bool, externalTor bool) {
 	fmt.Printf("Files will be saved to: %s/\n", receiveDir)
-	fmt.Println("Listening...")
-	
+
 	// Ensure receive directory exists
 	if err := os.MkdirAll(receiveDir, 0755); err != nil {
 		log.Fatalf("Cannot create directory: %v", err)
 	}
-	
-	// Start TCP listener
-	listener, err := net.Listen("tcp", port)
-	if err != nil {
-		log.Fatalf("Cannot start server: %v", err)
+
+	var listener net.Listener
+
+	if externalTor {
+		// Use a separately-run Tor with a hidden service already pointed at our port.
+		l, err := net.Listen("tcp", port)
+		if err != nil {
+			log.Fatalf("Cannot start server: %v", err)
+		}
+		listener = l
+		fmt.Println("Listening for connections via externally configured Tor hidden service...")
+	} else {
+		t, onion, address, err := startEmbeddedTorListener(torServicePort)
+		if err != nil {
+			log.Fatalf("Cannot start embedded Tor: %v", err)
+		}
+		defer t.Close()
+		listener = onion
+		fmt.Printf("Onion address: %s\n", address)
+		fmt.Println("Waiting for the onion service to become reachable, then listening...")
 	}
 	defer listener.Close()
-	
+
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			log.Printf("Connection error: %v", err)
 			continue
 		}
-		
+
 		go func(c net.Conn) {
 			defer c.Close()
-			if err := receiveFileRaw(c); err != nil {
+
+			secureConn, err := secureHandshake(c, code, false)
+			if err != nil {
+				log.Printf("Rejecting connection, PAKE handshake failed: %v", err)
+				return
+			}
+
+			if err := receiveFileRaw(secureConn, allowSymlinks); err != nil {
 				log.Printf("Error receiving file: %v", err)
 			}
 		}(conn)
 	}
 }
 
-// sendFilesRaw sends files using raw TCP connection via Tor
-func sendFilesRaw(target string, filePatterns []string) error {
+// sendFilesRaw sends files using raw TCP connection via Tor, sharding
+// whole files/directories round-robin across streams concurrent
+// connections. A transfer of a single large file does not benefit from
+// streams > 1: there is no byte-range splitting of one file across
+// streams, so it is always sent whole over one connection (see the
+// round-robin comment below). Multiple smaller files/directories are
+// where -streams pays off.
+func sendFilesRaw(target string, filePatterns []string, code string, compress bool, level zstd.EncoderLevel, externalTor bool, streams int) error {
 	startTime = time.Now()
-	
+
 	// Clean target address (remove port if present)
 	target = strings.Split(target, ":")[0]
 	fullTarget := target + port
-		
-	// Create Tor dialer
-	dialer, err := proxy.SOCKS5("tcp", torProxyAddr, nil, proxy.Direct)
-	if err != nil {
-		return fmt.Errorf("can't connect to Tor proxy: %v", err)
-	}
-	
-	// Establish connection via Tor
-	conn, err := dialer.Dial("tcp", fullTarget)
-	if err != nil {
-		return fmt.Errorf("failed to connect to target: %w", err)
-	}
-	defer conn.Close()
-	
+
 	// Collect all items to send
 	var itemsToSend []string
 	for _, pattern := range filePatterns {
 		// Check if pattern contains wildcards
-		if strings.Contains(pattern, "*") || strings.Contains(pattern, "?") || 
-		   strings.Contains(pattern, "[") {
+		if strings.Contains(pattern, "*") || strings.Contains(pattern, "?") ||
+			strings.Contains(pattern, "[") {
 			// Use glob for patterns
 			matches, err := filepath.Glob(pattern)
 			if err != nil {
@@ -555,7 +583,7 @@ func sendFilesRaw(target string, filePatterns []string) error {
 			itemsToSend = append(itemsToSend, pattern)
 		}
 	}
-	
+
 	// Remove duplicates
 	seen := make(map[string]bool)
 	var uniqueItems []string
@@ -565,55 +593,158 @@ func sendFilesRaw(target string, filePatterns []string) error {
 			uniqueItems = append(uniqueItems, item)
 		}
 	}
-	
+
 	if len(uniqueItems) == 0 {
 		return fmt.Errorf("no files or directories found matching patterns: %v", filePatterns)
 	}
-	
-	fmt.Printf("Found %d item(s) to send\n", len(uniqueItems))
-	
-	// Send each item
-	for i, itemPath := range uniqueItems {
-		fmt.Printf("[%d/%d] ", i+1, len(uniqueItems))
-		
-		if err := sendFileRaw(conn, itemPath); err != nil {
-			return fmt.Errorf("failed to send %s: %w", itemPath, err)
+
+	if streams < 1 {
+		streams = 1
+	}
+	if streams > len(uniqueItems) {
+		streams = len(uniqueItems)
+	}
+
+	fmt.Printf("Found %d item(s) to send across %d stream(s)\n", len(uniqueItems), streams)
+
+	// Shard items round-robin across the worker streams. Only whole items
+	// (files or entire directories) are assigned as work units; a single
+	// large file is not itself split across streams.
+	shards := make([][]string, streams)
+	for i, item := range uniqueItems {
+		shards[i%streams] = append(shards[i%streams], item)
+	}
+
+	if streams > 1 {
+		streamAgg = newStreamProgress(totalItemBytes(uniqueItems))
+		defer func() {
+			streamAgg.finalize()
+			streamAgg = nil
+		}()
+	}
+
+	// All streams share one embedded Tor daemon and open their own SOCKS
+	// connection to it, rather than each bootstrapping its own daemon.
+	var sharedDialer *tor.Dialer
+	if !externalTor {
+		t, dialer, err := startEmbeddedTorDialer()
+		if err != nil {
+			return err
 		}
-		
-		if i < len(uniqueItems)-1 {
-			time.Sleep(100 * time.Millisecond)
+		defer t.Close()
+		sharedDialer = dialer
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, streams)
+
+	for streamIndex, items := range shards {
+		if len(items) == 0 {
+			continue
 		}
+
+		wg.Add(1)
+		go func(streamIndex int, items []string) {
+			defer wg.Done()
+
+			rawConn, err := dialTargetMulti(fullTarget, externalTor, sharedDialer)
+			if err != nil {
+				errCh <- fmt.Errorf("stream %d: %w", streamIndex, err)
+				return
+			}
+			defer rawConn.Close()
+
+			secureConn, err := secureHandshake(rawConn, code, true)
+			if err != nil {
+				errCh <- fmt.Errorf("stream %d: PAKE handshake failed: %w", streamIndex, err)
+				return
+			}
+
+			if err := sendCompressionChoice(secureConn, compress); err != nil {
+				errCh <- fmt.Errorf("stream %d: failed to negotiate compression: %w", streamIndex, err)
+				return
+			}
+
+			conn, err := newCompressedConn(secureConn, compress, level)
+			if err != nil {
+				errCh <- fmt.Errorf("stream %d: failed to set up compression: %w", streamIndex, err)
+				return
+			}
+			defer conn.Close()
+
+			for _, itemPath := range items {
+				if streamAgg == nil {
+					fmt.Printf("[stream %d] ", streamIndex)
+				}
+				if err := sendFileRaw(conn, itemPath); err != nil {
+					errCh <- fmt.Errorf("stream %d: failed to send %s: %w", streamIndex, itemPath, err)
+					return
+				}
+			}
+		}(streamIndex, items)
 	}
-	
-	conn.Close()
-	
+
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			return err
+		}
+	}
+
 	totalTime := time.Since(startTime)
 	fmt.Printf("\nAll items transferred successfully in %s\n", formatDuration(totalTime))
-	
+
 	return nil
 }
 
+// totalItemBytes sums the on-disk size of files among items, used only to
+// size the aggregate multi-stream progress bar; directories are walked too
+// so their contents count toward the total.
+func totalItemBytes(items []string) int64 {
+	var total int64
+	for _, item := range items {
+		filepath.Walk(item, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
+
 // sendStdinRaw sends data from stdin as a file
-func sendStdinRaw(target string) error {
+func sendStdinRaw(target string, code string, compress bool, level zstd.EncoderLevel, externalTor bool) error {
 	startTime = time.Now()
-	
+
 	// Clean target address
 	target = strings.Split(target, ":")[0]
 	fullTarget := target + port
-		
-	// Create Tor dialer
-	dialer, err := proxy.SOCKS5("tcp", torProxyAddr, nil, proxy.Direct)
+
+	rawConn, torCloser, err := dialTarget(fullTarget, externalTor)
 	if err != nil {
-		return fmt.Errorf("can't connect to Tor proxy: %v", err)
+		return err
 	}
-	
-	// Establish connection via Tor
-	conn, err := dialer.Dial("tcp", fullTarget)
+	defer rawConn.Close()
+	if torCloser != nil {
+		defer torCloser.Close()
+	}
+
+	secureConn, err := secureHandshake(rawConn, code, true)
 	if err != nil {
-		return fmt.Errorf("failed to connect to target: %w", err)
+		return fmt.Errorf("PAKE handshake failed: %w", err)
 	}
-	defer conn.Close()
-	
+
+	if err := sendCompressionChoice(secureConn, compress); err != nil {
+		return fmt.Errorf("failed to negotiate compression: %w", err)
+	}
+
+	conn, err := newCompressedConn(secureConn, compress, level)
+	if err != nil {
+		return fmt.Errorf("failed to set up compression: %w", err)
+	}
+
 	// Read all data from stdin
 	fmt.Println("Reading from stdin...")
 	data, err := io.ReadAll(os.Stdin)
@@ -681,26 +812,65 @@ func sendStdinRaw(target string) error {
 }
 
 func main() {
+	codeFlag := flag.String("code", "", "shared secret/code phrase used to PAKE-authenticate and encrypt the transfer (required)")
+	allowSymlinksFlag := flag.Bool("allow-symlinks", false, "recreate symlinks from received directory archives instead of skipping them")
+	compressFlag := flag.Bool("compress", true, "zstd-compress the transfer (sender only)")
+	noCompressFlag := flag.Bool("nocompress", false, "disable zstd compression, overriding -compress (sender only)")
+	levelFlag := flag.Int("level", int(zstd.SpeedDefault), "zstd compression level, 1 (fastest) to 4 (best compression)")
+	externalTorFlag := flag.Bool("external-tor", false, "use a separately-run system Tor instead of launching an embedded one")
+	streamsFlag := flag.Int("streams", 4, "number of parallel Tor streams to shard whole files/directories across (sender only; a single large file is never itself split across streams)")
+	mountFlag := flag.String("mount", "", "receiver only: mount incoming files read-only at this path via FUSE instead of saving them to received/")
+	serveFlag := flag.Bool("serve", false, "sender only: keep the connection open and serve byte ranges on demand for a peer running -mount, instead of pushing files")
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "OnionTransfer - File transfer over Tor\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
 		fmt.Fprintf(os.Stderr, "  Receiver mode (listen for files):\n")
-		fmt.Fprintf(os.Stderr, "    %s\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "    %s -code <shared-secret>\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  Sender mode (send files):\n")
-		fmt.Fprintf(os.Stderr, "    %s <onion-address> file1.txt file2.jpg *.png directory/\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "    %s -code <shared-secret> <onion-address> file1.txt file2.jpg *.png directory/\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  Mount mode (browse incoming files without downloading them):\n")
+		fmt.Fprintf(os.Stderr, "    %s -code <shared-secret> -mount <dir>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "    %s -code <shared-secret> -serve <onion-address> file1.txt directory/\n", os.Args[0])
 	}
-	
+
 	flag.Parse()
-	
+
+	if *codeFlag == "" {
+		fmt.Println("Error: -code <shared-secret> is required to authenticate and encrypt the transfer")
+		fmt.Println()
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	compress := *compressFlag && !*noCompressFlag
+	level := zstd.EncoderLevel(*levelFlag)
+
 	// If arguments exist, use sender mode
 	if len(flag.Args()) > 0 {
 		target := flag.Args()[0]
-		
+
+		if *serveFlag {
+			if len(flag.Args()) < 2 {
+				fmt.Println("Error: Please specify files or directories to serve")
+				fmt.Println()
+				flag.Usage()
+				os.Exit(1)
+			}
+			filePatterns := flag.Args()[1:]
+			err := serveFilesRaw(target, filePatterns, *codeFlag, compress, level, *externalTorFlag)
+			if err != nil {
+				fmt.Printf("Error serving files: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		// Check if data is coming from stdin
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeCharDevice) == 0 {
 			// Stdin has data
-			err := sendStdinRaw(target)
+			err := sendStdinRaw(target, *codeFlag, compress, level, *externalTorFlag)
 			if err != nil {
 				fmt.Printf("Error sending from stdin: %v\n", err)
 				os.Exit(1)
@@ -708,7 +878,7 @@ func main() {
 		} else if len(flag.Args()) > 1 {
 			// Send files matching patterns
 			filePatterns := flag.Args()[1:]
-			err := sendFilesRaw(target, filePatterns)
+			err := sendFilesRaw(target, filePatterns, *codeFlag, compress, level, *externalTorFlag, *streamsFlag)
 			if err != nil {
 				fmt.Printf("Error sending files: %v\n", err)
 				os.Exit(1)
@@ -720,8 +890,14 @@ func main() {
 			flag.Usage()
 			os.Exit(1)
 		}
+	} else if *mountFlag != "" {
+		// Mount mode: expose incoming files as a read-only FUSE filesystem.
+		if err := runMount(*mountFlag, *codeFlag, *externalTorFlag); err != nil {
+			fmt.Printf("Error mounting: %v\n", err)
+			os.Exit(1)
+		}
 	} else {
 		// Otherwise use listener mode
-		startServerRaw()
+		startServerRaw(*codeFlag, *allowSymlinksFlag, *externalTorFlag)
 	}
 }
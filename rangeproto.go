@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// rangeRequest asks a -serve sender for length bytes of filename starting
+// at offset. serveStreamable treats a zero Length with an empty Filename as
+// the end of the session; the -mount side never sends one and ends the
+// session by closing the connection.
+type rangeRequest struct {
+	Filename string
+	Offset   int64
+	Length   int32
+}
+
+// sendRangeRequest writes a rangeRequest in the same length-prefixed style
+// as sendFileInfo.
+func sendRangeRequest(conn net.Conn, req rangeRequest) error {
+	nameBytes := []byte(req.Filename)
+	if err := binary.Write(conn, binary.BigEndian, uint32(len(nameBytes))); err != nil {
+		return err
+	}
+	if _, err := conn.Write(nameBytes); err != nil {
+		return err
+	}
+	if err := binary.Write(conn, binary.BigEndian, req.Offset); err != nil {
+		return err
+	}
+	return binary.Write(conn, binary.BigEndian, req.Length)
+}
+
+// receiveRangeRequest reads a rangeRequest written by sendRangeRequest.
+func receiveRangeRequest(conn net.Conn) (rangeRequest, error) {
+	var req rangeRequest
+
+	var nameLen uint32
+	if err := binary.Read(conn, binary.BigEndian, &nameLen); err != nil {
+		return req, err
+	}
+	if nameLen > maxFilenameLen {
+		return req, fmt.Errorf("filename too long: %d bytes", nameLen)
+	}
+	nameBytes := make([]byte, nameLen)
+	if _, err := io.ReadFull(conn, nameBytes); err != nil {
+		return req, err
+	}
+	req.Filename = string(nameBytes)
+
+	if err := binary.Read(conn, binary.BigEndian, &req.Offset); err != nil {
+		return req, err
+	}
+	if err := binary.Read(conn, binary.BigEndian, &req.Length); err != nil {
+		return req, err
+	}
+	if req.Length < 0 || req.Length > mountBlockSize {
+		return req, fmt.Errorf("range request length out of bounds: %d", req.Length)
+	}
+	return req, nil
+}
+
+// sendRangeData replies to a rangeRequest with the bytes read, length-prefixed.
+func sendRangeData(conn net.Conn, data []byte) error {
+	if err := binary.Write(conn, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+// receiveRangeData reads a reply written by sendRangeData.
+func receiveRangeData(conn net.Conn) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > mountBlockSize {
+		return nil, fmt.Errorf("range data too long: %d bytes", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
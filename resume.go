@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const manifestBlockSize = 1 * 1024 * 1024 // Block size used for resume hashing
+
+// computeManifest hashes filePath into a full-content BLAKE2b digest plus a
+// BLAKE2b digest per fixed-size block, so the receiver can later figure out
+// how much of a partially-received file is already correct.
+func computeManifest(filePath string) (fullHash [32]byte, blockHashes [][32]byte, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fullHash, nil, err
+	}
+	defer file.Close()
+
+	fullHasher, err := blake2b.New256(nil)
+	if err != nil {
+		return fullHash, nil, err
+	}
+
+	buffer := make([]byte, manifestBlockSize)
+	for {
+		n, readErr := io.ReadFull(file, buffer)
+		if n > 0 {
+			block := buffer[:n]
+			fullHasher.Write(block)
+
+			blockHasher, herr := blake2b.New256(nil)
+			if herr != nil {
+				return fullHash, nil, herr
+			}
+			blockHasher.Write(block)
+
+			var blockHash [32]byte
+			copy(blockHash[:], blockHasher.Sum(nil))
+			blockHashes = append(blockHashes, blockHash)
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fullHash, nil, readErr
+		}
+	}
+
+	copy(fullHash[:], fullHasher.Sum(nil))
+	return fullHash, blockHashes, nil
+}
+
+// findResumeOffset compares blockHashes against a partially-downloaded file
+// at partPath (if any) and returns how many leading bytes already match, so
+// the sender can be asked to resume from that offset.
+func findResumeOffset(partPath string, blockHashes [][32]byte, totalSize int64) (int64, error) {
+	file, err := os.Open(partPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	var matched int64
+	buffer := make([]byte, manifestBlockSize)
+
+	for _, want := range blockHashes {
+		remaining := totalSize - matched
+		blockLen := int64(manifestBlockSize)
+		if remaining < blockLen {
+			blockLen = remaining
+		}
+		if blockLen <= 0 {
+			break
+		}
+
+		n, err := io.ReadFull(file, buffer[:blockLen])
+		if err != nil {
+			// Partial file ends before this block; nothing more to verify.
+			break
+		}
+
+		blockHasher, herr := blake2b.New256(nil)
+		if herr != nil {
+			return matched, herr
+		}
+		blockHasher.Write(buffer[:n])
+
+		var got [32]byte
+		copy(got[:], blockHasher.Sum(nil))
+		if got != want {
+			break
+		}
+
+		matched += int64(n)
+	}
+
+	return matched, nil
+}
+
+// verifyFullHash checks that the content at path hashes to want.
+func verifyFullHash(path string, want [32]byte) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	hasher, err := blake2b.New256(nil)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(hasher, file); err != nil {
+		return false, err
+	}
+
+	var got [32]byte
+	copy(got[:], hasher.Sum(nil))
+	return got == want, nil
+}
+
+// sendManifest writes a resume manifest: the full-content hash followed by
+// the number of block hashes and the block hashes themselves.
+func sendManifest(conn net.Conn, fullHash [32]byte, blockHashes [][32]byte) error {
+	if _, err := conn.Write(fullHash[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(conn, binary.BigEndian, uint32(len(blockHashes))); err != nil {
+		return err
+	}
+	for _, h := range blockHashes {
+		if _, err := conn.Write(h[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveManifest reads back a manifest written by sendManifest.
+func receiveManifest(conn net.Conn) (fullHash [32]byte, blockHashes [][32]byte, err error) {
+	if _, err = io.ReadFull(conn, fullHash[:]); err != nil {
+		return
+	}
+
+	var count uint32
+	if err = binary.Read(conn, binary.BigEndian, &count); err != nil {
+		return
+	}
+
+	blockHashes = make([][32]byte, count)
+	for i := range blockHashes {
+		if _, err = io.ReadFull(conn, blockHashes[i][:]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sendResumeOffset tells the sender how many bytes of the file it can skip.
+func sendResumeOffset(conn net.Conn, offset int64) error {
+	return binary.Write(conn, binary.BigEndian, offset)
+}
+
+// receiveResumeOffset reads the offset sent by sendResumeOffset.
+func receiveResumeOffset(conn net.Conn) (int64, error) {
+	var offset int64
+	err := binary.Read(conn, binary.BigEndian, &offset)
+	return offset, err
+}
+
+// receiveFileBody streams a file's contents into path, resuming at
+// resumeOffset when one was negotiated via a resume manifest.
+func receiveFileBody(conn net.Conn, path string, fi *FileInfo, resumeOffset int64) error {
+	flags := os.O_CREATE | os.O_WRONLY
+	if resumeOffset > 0 {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot create file: %w", err)
+	}
+
+	if resumeOffset > 0 {
+		if err := file.Truncate(resumeOffset); err != nil {
+			file.Close()
+			return fmt.Errorf("cannot truncate partial file: %w", err)
+		}
+		fmt.Printf("Resuming %s at %s of %s...\n", fi.Filename, formatBytes(float64(resumeOffset)), formatBytes(float64(fi.FileSize)))
+	} else {
+		fmt.Printf("Receiving %s (%s)...\n", fi.Filename, formatBytes(float64(fi.FileSize)))
+	}
+
+	// Each accepted connection runs receiveFileBody in its own goroutine
+	// (see startServerRaw), so with -streams >1 this must not touch the
+	// package-global progressWriter shared with the single-stream senders.
+	progressWriter := NewProgressWriter(fi.FileSize, filepath.Base(fi.Filename))
+	progressWriter.current = resumeOffset
+
+	buffer := make([]byte, chunkSize)
+	totalReceived := resumeOffset
+
+	for totalReceived < fi.FileSize {
+		remaining := fi.FileSize - totalReceived
+		readSize := chunkSize
+		if remaining < int64(chunkSize) {
+			readSize = int(remaining)
+		}
+
+		n, err := io.ReadFull(conn, buffer[:readSize])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			file.Close()
+			return err
+		}
+
+		if _, err := file.Write(buffer[:n]); err != nil {
+			file.Close()
+			return err
+		}
+
+		totalReceived += int64(n)
+		progressWriter.current = totalReceived
+		progressWriter.printProgress()
+
+		if totalReceived >= fi.FileSize {
+			break
+		}
+	}
+
+	file.Close()
+
+	progressWriter.current = fi.FileSize
+	progressWriter.printProgress()
+	progressWriter.Finalize()
+
+	return nil
+}
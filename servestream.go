@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// serveFilesRaw dials target once, announces a catalog of every file under
+// filePatterns (expanding directories), and then serves byte-range requests
+// for them on demand, for a peer running in -mount mode. Unlike
+// sendFilesRaw, transfers are not sharded across multiple streams: a mount
+// session keeps one persistent, bidirectional connection for its lifetime.
+func serveFilesRaw(target string, filePatterns []string, code string, compress bool, level zstd.EncoderLevel, externalTor bool) error {
+	startTime = time.Now()
+
+	target = strings.Split(target, ":")[0]
+	fullTarget := target + port
+
+	var itemsToServe []string
+	for _, pattern := range filePatterns {
+		if strings.Contains(pattern, "*") || strings.Contains(pattern, "?") ||
+			strings.Contains(pattern, "[") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern %s: %w", pattern, err)
+			}
+			itemsToServe = append(itemsToServe, matches...)
+		} else {
+			itemsToServe = append(itemsToServe, pattern)
+		}
+	}
+
+	if len(itemsToServe) == 0 {
+		return fmt.Errorf("no files or directories found matching patterns: %v", filePatterns)
+	}
+
+	rawConn, torCloser, err := dialTarget(fullTarget, externalTor)
+	if err != nil {
+		return err
+	}
+	defer rawConn.Close()
+	if torCloser != nil {
+		defer torCloser.Close()
+	}
+
+	secureConn, err := secureHandshake(rawConn, code, true)
+	if err != nil {
+		return fmt.Errorf("PAKE handshake failed: %w", err)
+	}
+
+	if err := sendCompressionChoice(secureConn, compress); err != nil {
+		return fmt.Errorf("failed to negotiate compression: %w", err)
+	}
+
+	conn, err := newCompressedConn(secureConn, compress, level)
+	if err != nil {
+		return fmt.Errorf("failed to set up compression: %w", err)
+	}
+	defer conn.Close()
+
+	if err := serveStreamable(conn, itemsToServe); err != nil {
+		return err
+	}
+
+	totalTime := time.Since(startTime)
+	fmt.Printf("\nMount session served in %s\n", formatDuration(totalTime))
+
+	return nil
+}
+
+// catalogEntry maps the relative path announced to a mount peer back to the
+// file on disk it is served from.
+type catalogEntry struct {
+	relPath string
+	absPath string
+	size    int64
+}
+
+// serveStreamable sends a catalog of every file under items (a single
+// FileInfo per file, directories expanded recursively), marked Streamable,
+// terminated by an EndOfCatalog marker, then answers rangeRequests against
+// that catalog until the peer sends the end-of-session sentinel or closes
+// the connection.
+func serveStreamable(conn net.Conn, items []string) error {
+	catalog, err := buildCatalog(items)
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]string, len(catalog))
+	for _, entry := range catalog {
+		fi := FileInfo{Filename: entry.relPath, FileSize: entry.size, Streamable: true}
+		if err := sendFileInfo(conn, fi); err != nil {
+			return fmt.Errorf("failed to send catalog entry %s: %w", entry.relPath, err)
+		}
+		paths[entry.relPath] = entry.absPath
+	}
+	if err := sendFileInfo(conn, FileInfo{EndOfCatalog: true}); err != nil {
+		return fmt.Errorf("failed to send end of catalog: %w", err)
+	}
+
+	fmt.Printf("Serving %d file(s) on demand for a remote mount...\n", len(catalog))
+
+	for {
+		req, err := receiveRangeRequest(conn)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to receive range request: %w", err)
+		}
+		if req.Length == 0 && req.Filename == "" {
+			return nil
+		}
+
+		absPath, ok := paths[req.Filename]
+		if !ok {
+			if err := sendRangeData(conn, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := readRange(absPath, req.Offset, int(req.Length))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", req.Filename, err)
+		}
+		if err := sendRangeData(conn, data); err != nil {
+			return err
+		}
+	}
+}
+
+func buildCatalog(items []string) ([]catalogEntry, error) {
+	var catalog []catalogEntry
+
+	for _, item := range items {
+		info, err := os.Stat(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			catalog = append(catalog, catalogEntry{relPath: filepath.Base(item), absPath: item, size: info.Size()})
+			continue
+		}
+
+		baseName := filepath.Base(item)
+		err = filepath.Walk(item, func(path string, fi os.FileInfo, walkErr error) error {
+			if walkErr != nil || fi.IsDir() {
+				return walkErr
+			}
+			rel, err := filepath.Rel(item, path)
+			if err != nil {
+				return err
+			}
+			catalog = append(catalog, catalogEntry{
+				relPath: filepath.ToSlash(filepath.Join(baseName, rel)),
+				absPath: path,
+				size:    fi.Size(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", item, err)
+		}
+	}
+
+	return catalog, nil
+}
+
+// readRange reads up to length bytes of path starting at offset.
+func readRange(path string, offset int64, length int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, length)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
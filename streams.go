@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamAgg, when non-nil, aggregates bytes sent across multiple concurrent
+// sender streams into a single printed progress line, replacing the
+// per-file ProgressWriter used in single-stream mode.
+var streamAgg *streamProgress
+
+// streamProgress tracks combined progress across all worker streams of a
+// multi-stream transfer.
+type streamProgress struct {
+	total     int64
+	current   int64 // accessed atomically
+	startTime time.Time
+	mu        sync.Mutex
+	lastPrint int64
+}
+
+// newStreamProgress creates a tracker for a transfer of total bytes spread
+// across an arbitrary number of concurrent streams.
+func newStreamProgress(total int64) *streamProgress {
+	return &streamProgress{total: total, startTime: time.Now()}
+}
+
+// add records n more bytes sent by any worker stream and refreshes the
+// printed progress line.
+func (sp *streamProgress) add(n int64) {
+	current := atomic.AddInt64(&sp.current, n)
+	sp.print(current)
+}
+
+// print throttles console updates to avoid interleaved spam from concurrent streams.
+func (sp *streamProgress) print(current int64) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if current-sp.lastPrint < 64*1024 && current < sp.total {
+		return
+	}
+	sp.lastPrint = current
+
+	elapsed := time.Since(sp.startTime)
+	speed := float64(current) / elapsed.Seconds()
+
+	var percent float64
+	if sp.total > 0 {
+		percent = float64(current) / float64(sp.total) * 100
+	}
+
+	fmt.Printf("\rAll streams: %s/%s (%.1f%%) | Speed: %s",
+		formatBytes(float64(current)), formatBytes(float64(sp.total)), percent, formatBytes(speed)+"/s")
+}
+
+// finalize prints the closing summary line once every stream has finished.
+func (sp *streamProgress) finalize() {
+	elapsed := time.Since(sp.startTime)
+	speed := float64(sp.total) / elapsed.Seconds()
+	fmt.Printf("\rAll streams: %s transferred in %s (%.1f MB/s)        \n",
+		formatBytes(float64(sp.total)), formatDuration(elapsed), speed/1024/1024)
+}
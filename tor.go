@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/cretz/bine/tor"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	torStartupTimeout = 3 * time.Minute // Time allowed for the embedded Tor daemon to bootstrap
+	torDialTimeout    = 3 * time.Minute // Time allowed for an embedded-Tor SOCKS dial to succeed
+)
+
+// startEmbeddedTorListener launches an in-process Tor daemon and publishes an
+// ephemeral v3 onion service that forwards localPort. The caller is
+// responsible for closing both the returned Tor instance and listener.
+func startEmbeddedTorListener(localPort int) (*tor.Tor, net.Listener, string, error) {
+	t, err := tor.Start(nil, nil)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to start embedded Tor: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), torStartupTimeout)
+	defer cancel()
+
+	onion, err := t.Listen(ctx, &tor.ListenConf{
+		Version3:    true,
+		RemotePorts: []int{localPort},
+	})
+	if err != nil {
+		t.Close()
+		return nil, nil, "", fmt.Errorf("failed to publish onion service: %w", err)
+	}
+
+	return t, onion, onion.ID + ".onion", nil
+}
+
+// startEmbeddedTorDialer launches an in-process Tor daemon and returns a
+// dialer that routes connections through it. The caller is responsible for
+// closing the returned Tor instance once done dialing.
+func startEmbeddedTorDialer() (*tor.Tor, *tor.Dialer, error) {
+	t, err := tor.Start(nil, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start embedded Tor: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), torStartupTimeout)
+	defer cancel()
+
+	dialer, err := t.Dialer(ctx, nil)
+	if err != nil {
+		t.Close()
+		return nil, nil, fmt.Errorf("failed to create embedded Tor dialer: %w", err)
+	}
+
+	return t, dialer, nil
+}
+
+// dialEmbeddedTor dials fullTarget through dialer, bounding the attempt with
+// torDialTimeout.
+func dialEmbeddedTor(dialer *tor.Dialer, fullTarget string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), torDialTimeout)
+	defer cancel()
+	return dialer.DialContext(ctx, "tcp", fullTarget)
+}
+
+// dialTargetMulti connects to fullTarget over Tor the same way dialTarget
+// does, except that when running an embedded Tor daemon it dials through a
+// dialer the caller already started instead of launching a fresh daemon.
+// This lets a multi-stream transfer open several SOCKS connections to the
+// same onion through one embedded Tor instance rather than bootstrapping
+// one daemon per stream.
+func dialTargetMulti(fullTarget string, externalTor bool, sharedDialer *tor.Dialer) (net.Conn, error) {
+	if externalTor {
+		dialer, err := proxy.SOCKS5("tcp", torProxyAddr, nil, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("can't connect to Tor proxy: %v", err)
+		}
+		conn, err := dialer.Dial("tcp", fullTarget)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to target: %w", err)
+		}
+		return conn, nil
+	}
+
+	conn, err := dialEmbeddedTor(sharedDialer, fullTarget)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to target: %w", err)
+	}
+	return conn, nil
+}
+
+// dialTarget connects to fullTarget over Tor: by default it launches an
+// in-process Tor daemon for the duration of the connection; with
+// externalTor it instead dials through an already-running system Tor's
+// SOCKS proxy. The returned io.Closer (nil when externalTor is true) owns
+// the embedded Tor daemon and must be closed once the connection is done.
+func dialTarget(fullTarget string, externalTor bool) (net.Conn, io.Closer, error) {
+	if externalTor {
+		dialer, err := proxy.SOCKS5("tcp", torProxyAddr, nil, proxy.Direct)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't connect to Tor proxy: %v", err)
+		}
+		conn, err := dialer.Dial("tcp", fullTarget)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to target: %w", err)
+		}
+		return conn, nil, nil
+	}
+
+	t, dialer, err := startEmbeddedTorDialer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := dialEmbeddedTor(dialer, fullTarget)
+	if err != nil {
+		t.Close()
+		return nil, nil, fmt.Errorf("failed to connect to target: %w", err)
+	}
+
+	return conn, t, nil
+}